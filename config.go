@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadConfig resolves configPath to one or more endpoint files and merges
+// them into a single Config. configPath may be a single file, a directory
+// (every *.json/*.yaml/*.yml inside it is loaded), or a glob pattern such
+// as "/config/*.yaml". Both JSON and YAML are supported, detected by file
+// extension.
+func loadConfig(configPath string) (*Config, error) {
+	files, err := resolveConfigFiles(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no config files matched %q", configPath)
+	}
+
+	merged := &Config{}
+	for _, file := range files {
+		cfg, err := loadConfigFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("loading %s: %w", file, err)
+		}
+		merged.Endpoints = append(merged.Endpoints, cfg.Endpoints...)
+		if cfg.Info != nil {
+			merged.Info = cfg.Info
+		}
+		if cfg.OpenAPI != nil {
+			merged.OpenAPI = cfg.OpenAPI
+		}
+		if cfg.FetchAllowlist != nil {
+			merged.FetchAllowlist = cfg.FetchAllowlist
+		}
+		if cfg.StateFile != "" {
+			merged.StateFile = cfg.StateFile
+		}
+	}
+
+	if merged.OpenAPI != nil {
+		doc, err := loadExternalOpenAPI(merged)
+		if err != nil {
+			return nil, fmt.Errorf("loading openapi spec: %w", err)
+		}
+		mergeOpenAPIEndpoints(merged, doc)
+	}
+
+	return merged, nil
+}
+
+// resolveConfigFiles expands configPath into a sorted list of concrete
+// file paths.
+func resolveConfigFiles(configPath string) ([]string, error) {
+	if strings.ContainsAny(configPath, "*?[") {
+		matches, err := filepath.Glob(configPath)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		return matches, nil
+	}
+
+	info, err := os.Stat(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{configPath}, nil
+	}
+
+	var files []string
+	entries, err := os.ReadDir(configPath)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !isConfigFile(entry.Name()) {
+			continue
+		}
+		files = append(files, filepath.Join(configPath, entry.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func isConfigFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	default:
+		return false
+	}
+}
+
+func loadConfigFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, err
+		}
+	default:
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, err
+		}
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// GRPCConfig drives a protocol: grpc endpoint: it isn't served from the
+// HTTP mux at all, but from a second grpc.Server responding to unary calls
+// for Service/Method with Response (or Script's result) marshaled through
+// Proto's message descriptors.
+type GRPCConfig struct {
+	Proto   string `json:"proto" yaml:"proto"`
+	Service string `json:"service" yaml:"service"`
+	Method  string `json:"method" yaml:"method"`
+}
+
+// grpcRoute is one resolved fullMethod -> endpoint mapping, built once at
+// startup from every protocol: grpc endpoint in the config.
+type grpcRoute struct {
+	endpoint *Endpoint
+	reqDesc  protoreflect.MessageDescriptor
+	respDesc protoreflect.MessageDescriptor
+}
+
+// startGRPCServer starts a grpc.Server on addr serving every protocol: grpc
+// endpoint in endpoints, or returns a nil server (and nil error) if there
+// are none. gRPC endpoints are resolved from the config at startup and are
+// not affected by config hot-reload.
+func startGRPCServer(addr string, endpoints []Endpoint, deps *serverDeps) (*grpc.Server, error) {
+	routes := make(map[string]*grpcRoute)
+	for i := range endpoints {
+		ep := &endpoints[i]
+		if ep.Protocol != "grpc" || ep.GRPC == nil {
+			continue
+		}
+		route, err := buildGRPCRoute(ep)
+		if err != nil {
+			log.Printf("skipping grpc endpoint %s/%s: %v", ep.GRPC.Service, ep.GRPC.Method, err)
+			continue
+		}
+		routes["/"+ep.GRPC.Service+"/"+ep.GRPC.Method] = route
+	}
+
+	if len(routes) == 0 {
+		return nil, nil
+	}
+
+	server := grpc.NewServer(grpc.UnknownServiceHandler(grpcUnaryProxy(routes, deps)))
+	reflection.Register(server)
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		log.Printf("gRPC server starting on %s", addr)
+		if err := server.Serve(lis); err != nil {
+			log.Printf("gRPC server stopped: %v", err)
+		}
+	}()
+
+	return server, nil
+}
+
+func buildGRPCRoute(ep *Endpoint) (*grpcRoute, error) {
+	parser := protoparse.Parser{ImportPaths: []string{"."}}
+	fds, err := parser.ParseFiles(ep.GRPC.Proto)
+	if err != nil {
+		return nil, fmt.Errorf("parsing proto: %w", err)
+	}
+	if len(fds) == 0 {
+		return nil, fmt.Errorf("no file descriptors parsed from %s", ep.GRPC.Proto)
+	}
+
+	fileDesc, err := protodesc.NewFile(fds[0].AsFileDescriptorProto(), protoregistry.GlobalFiles)
+	if err != nil {
+		return nil, fmt.Errorf("converting file descriptor: %w", err)
+	}
+
+	svc := fileDesc.Services().ByName(protoreflect.Name(ep.GRPC.Service))
+	if svc == nil {
+		return nil, fmt.Errorf("service %s not found in %s", ep.GRPC.Service, ep.GRPC.Proto)
+	}
+	method := svc.Methods().ByName(protoreflect.Name(ep.GRPC.Method))
+	if method == nil {
+		return nil, fmt.Errorf("method %s not found on service %s", ep.GRPC.Method, ep.GRPC.Service)
+	}
+
+	return &grpcRoute{endpoint: ep, reqDesc: method.Input(), respDesc: method.Output()}, nil
+}
+
+// grpcUnaryProxy decodes every call the configured routes cover into a
+// dynamicpb message, runs the endpoint's normal static/script response
+// logic, and re-encodes the result through the response message's
+// descriptor.
+func grpcUnaryProxy(routes map[string]*grpcRoute, deps *serverDeps) grpc.StreamHandler {
+	return func(srv any, stream grpc.ServerStream) error {
+		fullMethod, ok := grpc.MethodFromServerStream(stream)
+		if !ok {
+			return status.Error(codes.Internal, "could not determine method")
+		}
+		route, ok := routes[fullMethod]
+		if !ok {
+			return status.Errorf(codes.Unimplemented, "unknown method %s", fullMethod)
+		}
+
+		reqMsg := dynamicpb.NewMessage(route.reqDesc)
+		if err := stream.RecvMsg(reqMsg); err != nil {
+			return status.Errorf(codes.Internal, "receiving request: %v", err)
+		}
+
+		reqJSON, err := protojson.Marshal(reqMsg)
+		if err != nil {
+			return status.Errorf(codes.Internal, "marshaling request: %v", err)
+		}
+		var reqBody any
+		_ = json.Unmarshal(reqJSON, &reqBody)
+
+		respBody, err := renderGRPCResponse(route.endpoint, reqBody, deps)
+		if err != nil {
+			return status.Errorf(codes.Internal, "rendering response: %v", err)
+		}
+
+		respJSON, err := json.Marshal(respBody)
+		if err != nil {
+			return status.Errorf(codes.Internal, "marshaling response: %v", err)
+		}
+
+		respMsg := dynamicpb.NewMessage(route.respDesc)
+		if err := protojson.Unmarshal(respJSON, respMsg); err != nil {
+			return status.Errorf(codes.Internal, "response does not match %s: %v", route.respDesc.FullName(), err)
+		}
+
+		return stream.SendMsg(respMsg)
+	}
+}
+
+func renderGRPCResponse(ep *Endpoint, reqBody any, deps *serverDeps) (any, error) {
+	if ep.Script == "" {
+		return ep.Response, nil
+	}
+
+	reqData := RequestData{
+		Method: "GRPC",
+		Path:   "/" + ep.GRPC.Service + "/" + ep.GRPC.Method,
+		Body:   reqBody,
+	}
+	result, err := deps.jsRuntime.Execute(ep.Script, reqData, ep.Context, ep.ScriptTimeoutMs)
+	if err != nil {
+		return nil, err
+	}
+	return result.Body, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// statusRecorder wraps an http.ResponseWriter so the final status code can
+// be observed after the handler returns.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.status = code
+		rec.wroteHeader = true
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *statusRecorder) Write(b []byte) (int, error) {
+	if !rec.wroteHeader {
+		rec.WriteHeader(http.StatusOK)
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+// Hijack delegates to the wrapped ResponseWriter so chaos.dropConnection
+// (see scenarios.go) can still hijack the underlying connection even
+// though it only ever sees the instrumented writer. Embedding
+// http.ResponseWriter as an interface field doesn't promote Hijack, since
+// it isn't part of that interface.
+func (rec *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := rec.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("instrumented ResponseWriter does not support hijacking")
+	}
+	return hijacker.Hijack()
+}
+
+// instrumentHandler wraps next so every request updates metrics and emits a
+// request log line, regardless of whether the endpoint is static or
+// scripted.
+func instrumentHandler(metrics *MetricsRegistry, logger *RequestLogger, path string, next func(w http.ResponseWriter, r *http.Request) (scriptFailed bool, scriptMs float64)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		end := metrics.BeginRequest(r.Method, path)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		start := time.Now()
+		scriptFailed, scriptMs := next(rec, r)
+		duration := time.Since(start)
+
+		end(rec.status, scriptFailed)
+		logger.LogRequest(requestLogEntry{
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Status:     rec.status,
+			DurationMs: float64(duration.Microseconds()) / 1000.0,
+			ScriptMs:   scriptMs,
+			RemoteAddr: r.RemoteAddr,
+			RequestID:  generateUUID(),
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var fakeFirstNames = []string{"Alice", "Bob", "Carla", "Derek", "Elena", "Farid", "Grace", "Hiro", "Ines", "Jamal"}
+var fakeLastNames = []string{"Nguyen", "Smith", "Garcia", "Müller", "Kapoor", "Johansson", "Silva", "Kim", "Rossi", "Dupont"}
+
+// scriptAPIDoc documents the globals injected into every script VM, for
+// endpoints that declare a script; surfaced in the generated OpenAPI spec
+// as x-stub-script-api.
+var scriptAPIDoc = map[string]any{
+	"req/request": "RequestData: method, path, query, headers, body, params",
+	"console.log": "func(...any)",
+	"uuid":        "func() string",
+	"now":         "func() string (RFC3339 UTC)",
+	"timestamp":   "func() int64 (unix seconds)",
+	"fetch":       "func(url string, opts {method, headers, body}) {status, body}, or {error} on failure — host must be in config fetchAllowlist",
+	"state":       "get(key) any / set(key, value) — shared in-memory KV, optionally persisted via config stateFile",
+	"random":      "int(min, max) int / pick(arr) any",
+	"faker":       "name() string",
+	"res":         "status(code).header(k, v).json(obj) builder, alongside returning a plain {body, statusCode, headers} object",
+}
+
+// jsFetch backs the `fetch(url, opts)` global. It only reaches hosts on
+// jr.fetchAllowlist; with no allowlist configured, fetch is disabled.
+func (jr *JSRuntime) jsFetch(rawURL string, opts map[string]any) map[string]any {
+	if err := jr.checkFetchAllowed(rawURL); err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+
+	method := "GET"
+	var bodyReader io.Reader
+	var headers map[string]any
+	if opts != nil {
+		if m, ok := opts["method"].(string); ok && m != "" {
+			method = strings.ToUpper(m)
+		}
+		if h, ok := opts["headers"].(map[string]any); ok {
+			headers = h
+		}
+		if b, ok := opts["body"]; ok {
+			switch v := b.(type) {
+			case string:
+				bodyReader = strings.NewReader(v)
+			default:
+				data, _ := json.Marshal(v)
+				bodyReader = bytes.NewReader(data)
+			}
+		}
+	}
+
+	req, err := http.NewRequest(method, rawURL, bodyReader)
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	for k, v := range headers {
+		if s, ok := v.(string); ok {
+			req.Header.Set(k, s)
+		}
+	}
+
+	resp, err := jr.httpClient.Do(req)
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return map[string]any{"error": err.Error()}
+	}
+
+	var parsed any
+	if json.Unmarshal(respBody, &parsed) != nil {
+		parsed = string(respBody)
+	}
+
+	return map[string]any{
+		"status": resp.StatusCode,
+		"body":   parsed,
+	}
+}
+
+func (jr *JSRuntime) checkFetchAllowed(rawURL string) error {
+	if len(jr.fetchAllowlist) == 0 {
+		return fmt.Errorf("fetch is disabled: no hosts in fetchAllowlist")
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid fetch url: %w", err)
+	}
+	if !jr.fetchAllowlist[parsed.Hostname()] {
+		return fmt.Errorf("fetch blocked: host %q is not in fetchAllowlist", parsed.Hostname())
+	}
+	return nil
+}
+
+func jsRandomInt(min, max int) int {
+	if max <= min {
+		return min
+	}
+	return min + rand.Intn(max-min+1)
+}
+
+func jsRandomPick(arr []any) any {
+	if len(arr) == 0 {
+		return nil
+	}
+	return arr[rand.Intn(len(arr))]
+}
+
+func fakerName() string {
+	return fakeFirstNames[rand.Intn(len(fakeFirstNames))] + " " + fakeLastNames[rand.Intn(len(fakeLastNames))]
+}
+
+// resBuilder backs the `res.status(code).header(k, v).json(obj)` builder
+// style response helper, alongside the existing plain map-return
+// convention. Json() returns a plain map so it flows through the same
+// ScriptResult extraction logic as a script that returns {body, ...}
+// directly.
+type resBuilder struct {
+	statusCode int
+	headers    map[string]string
+}
+
+func newResBuilder() *resBuilder {
+	return &resBuilder{statusCode: 200, headers: make(map[string]string)}
+}
+
+func (b *resBuilder) Status(code int) *resBuilder {
+	b.statusCode = code
+	return b
+}
+
+func (b *resBuilder) Header(key, value string) *resBuilder {
+	b.headers[key] = value
+	return b
+}
+
+func (b *resBuilder) Json(body any) map[string]any {
+	return map[string]any{
+		"statusCode": b.statusCode,
+		"headers":    b.headers,
+		"body":       body,
+	}
+}
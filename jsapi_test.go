@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJSRuntimeExecuteResBuilderUsesLowercaseAPI(t *testing.T) {
+	jr := NewJSRuntime(NewKVStore(""), nil)
+
+	result, err := jr.Execute(`res.status(201).header("X-Test", "yes").json({ok: true})`, RequestData{}, nil, 0)
+	if err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	if result.StatusCode != 201 {
+		t.Fatalf("StatusCode = %d; want 201", result.StatusCode)
+	}
+	if result.Headers["X-Test"] != "yes" {
+		t.Fatalf("Headers[X-Test] = %q; want %q", result.Headers["X-Test"], "yes")
+	}
+	body, ok := result.Body.(map[string]any)
+	if !ok || body["ok"] != true {
+		t.Fatalf("Body = %+v; want {ok: true}", result.Body)
+	}
+}
+
+func TestJSRuntimeExecuteRequestFieldsAreLowercase(t *testing.T) {
+	jr := NewJSRuntime(NewKVStore(""), nil)
+
+	req := RequestData{
+		Method: "GET",
+		Path:   "/users/42",
+		Params: map[string]string{"id": "42"},
+	}
+	result, err := jr.Execute(`({id: req.params.id, method: req.method})`, req, nil, 0)
+	if err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	body, ok := result.Body.(map[string]any)
+	if !ok || body["id"] != "42" || body["method"] != "GET" {
+		t.Fatalf("Body = %+v; want {id: 42, method: GET}", result.Body)
+	}
+}
+
+func TestJSRuntimeExecuteStateSharedAcrossCalls(t *testing.T) {
+	jr := NewJSRuntime(NewKVStore(""), nil)
+
+	if _, err := jr.Execute(`state.set("count", 1)`, RequestData{}, nil, 0); err != nil {
+		t.Fatalf("first Execute returned error: %v", err)
+	}
+
+	result, err := jr.Execute(`({count: state.get("count")})`, RequestData{}, nil, 0)
+	if err != nil {
+		t.Fatalf("second Execute returned error: %v", err)
+	}
+	body, ok := result.Body.(map[string]any)
+	if !ok || body["count"] != int64(1) {
+		t.Fatalf("Body = %+v; want {count: 1} set by a prior Execute call", result.Body)
+	}
+}
+
+func TestJSRuntimeExecuteFetchBlockedWithoutAllowlist(t *testing.T) {
+	jr := NewJSRuntime(NewKVStore(""), nil)
+
+	result, err := jr.Execute(`fetch("http://example.com")`, RequestData{}, nil, 0)
+	if err != nil {
+		t.Fatalf("Execute returned error: %v", err)
+	}
+	body, ok := result.Body.(map[string]any)
+	if !ok || body["error"] == nil {
+		t.Fatalf("Body = %+v; want a fetch error since fetchAllowlist is empty", result.Body)
+	}
+	if !strings.Contains(body["error"].(string), "fetchAllowlist") {
+		t.Fatalf("error = %q; want it to mention fetchAllowlist", body["error"])
+	}
+}
+
+func TestJSRuntimeExecuteTimeout(t *testing.T) {
+	jr := NewJSRuntime(NewKVStore(""), nil)
+
+	_, err := jr.Execute(`while (true) {}`, RequestData{}, nil, 50)
+	if err == nil {
+		t.Fatal("Execute returned nil error for a script that never terminates; want a timeout error")
+	}
+	if !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("error = %q; want it to mention the timeout", err.Error())
+	}
+}
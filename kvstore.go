@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// KVStore is a shared in-memory key/value store exposed to scripts as
+// state.get/state.set, letting a script simulate a stateful resource (e.g.
+// POST /users writing a record that a later GET /users/:id reads back). If
+// path is non-empty, every write is persisted so state survives restarts.
+type KVStore struct {
+	mu   sync.Mutex
+	path string
+	data map[string]any
+}
+
+func NewKVStore(path string) *KVStore {
+	s := &KVStore{path: path, data: make(map[string]any)}
+	if path == "" {
+		return s
+	}
+	if data, err := os.ReadFile(path); err == nil {
+		_ = json.Unmarshal(data, &s.data)
+	}
+	return s
+}
+
+func (s *KVStore) Get(key string) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data[key]
+}
+
+func (s *KVStore) Set(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[key] = value
+	s.persistLocked()
+}
+
+func (s *KVStore) persistLocked() {
+	if s.path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(s.data, "", "  ")
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(s.path, data, 0o644)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+)
+
+// requestLogEntry is one structured log line emitted per request when
+// --log-format=json is set.
+type requestLogEntry struct {
+	Method     string  `json:"method"`
+	Path       string  `json:"path"`
+	Status     int     `json:"status"`
+	DurationMs float64 `json:"duration_ms"`
+	ScriptMs   float64 `json:"script_ms,omitempty"`
+	RemoteAddr string  `json:"remote_addr"`
+	RequestID  string  `json:"request_id"`
+}
+
+// RequestLogger emits one line per request, either as plain text (the
+// existing behavior) or as JSON when logFormat is "json".
+type RequestLogger struct {
+	jsonFormat bool
+}
+
+func NewRequestLogger(format string) *RequestLogger {
+	return &RequestLogger{jsonFormat: format == "json"}
+}
+
+func (rl *RequestLogger) LogRequest(entry requestLogEntry) {
+	if !rl.jsonFormat {
+		log.Printf("%s %s -> %d (%.2fms)", entry.Method, entry.Path, entry.Status, entry.DurationMs)
+		return
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("failed to marshal request log entry: %v", err)
+		return
+	}
+	os.Stdout.Write(append(line, '\n'))
+}
@@ -2,7 +2,8 @@ package main
 
 import (
 	"encoding/json"
-	"io"
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -12,35 +13,83 @@ import (
 	"time"
 
 	"github.com/dop251/goja"
+	"github.com/getkin/kin-openapi/openapi3"
 )
 
 type Endpoint struct {
-	Path        string            `json:"path"`
-	Method      string            `json:"method"`
-	StatusCode  int               `json:"statusCode"`
-	Response    any               `json:"response"`
-	Headers     map[string]string `json:"headers"`
-	Summary     string            `json:"summary,omitempty"`
-	Description string            `json:"description,omitempty"`
-	Tags        []string          `json:"tags,omitempty"`
-	Script      string            `json:"script,omitempty"`
-	Context     map[string]any    `json:"context,omitempty"`
+	Path        string            `json:"path" yaml:"path"`
+	Method      string            `json:"method" yaml:"method"`
+	StatusCode  int               `json:"statusCode" yaml:"statusCode"`
+	Response    any               `json:"response" yaml:"response"`
+	Headers     map[string]string `json:"headers" yaml:"headers"`
+	Summary     string            `json:"summary,omitempty" yaml:"summary,omitempty"`
+	Description string            `json:"description,omitempty" yaml:"description,omitempty"`
+	Tags        []string          `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Script      string            `json:"script,omitempty" yaml:"script,omitempty"`
+	Context     map[string]any    `json:"context,omitempty" yaml:"context,omitempty"`
+	Proxy       *ProxyConfig      `json:"proxy,omitempty" yaml:"proxy,omitempty"`
+	// ScriptTimeoutMs bounds how long Script may run before it's
+	// interrupted; defaults to defaultScriptTimeoutMs.
+	ScriptTimeoutMs int `json:"scriptTimeoutMs,omitempty" yaml:"scriptTimeoutMs,omitempty"`
+	// Scenarios, when non-empty, replaces Response/Script as the source
+	// of truth for this endpoint: each request is matched against
+	// Scenarios in order and rendered via the winning one.
+	Scenarios []Scenario `json:"scenarios,omitempty" yaml:"scenarios,omitempty"`
+
+	// Protocol selects how this endpoint is served: "" or "http" (the
+	// default) serves it from the HTTP mux as usual, "grpc" serves it
+	// from the separate gRPC server (see GRPC), and "ws" upgrades the
+	// HTTP connection and drives it as a WebSocket (see WS).
+	Protocol string      `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+	GRPC     *GRPCConfig `json:"grpc,omitempty" yaml:"grpc,omitempty"`
+	WS       *WSConfig   `json:"ws,omitempty" yaml:"ws,omitempty"`
+
+	// specOp is set when this endpoint was generated from, or matched
+	// against, an operation in an external OpenAPI spec (see
+	// Config.OpenAPI). It drives request/response validation and is
+	// never serialized.
+	specOp *openapi3.Operation
 }
 
 type Config struct {
-	Endpoints []Endpoint `json:"endpoints"`
-	Info      *OpenAPIInfo `json:"info,omitempty"`
+	Endpoints []Endpoint   `json:"endpoints" yaml:"endpoints"`
+	Info      *OpenAPIInfo `json:"info,omitempty" yaml:"info,omitempty"`
+	// OpenAPI is either a path to an external OpenAPI spec file, or the
+	// spec itself inlined as an object. When set, every operation in the
+	// spec that isn't already covered by Endpoints is turned into a
+	// generated endpoint, and matching endpoints get request/response
+	// validation against it.
+	OpenAPI any `json:"openapi,omitempty" yaml:"openapi,omitempty"`
+	// FetchAllowlist lists the hosts scripts may reach via fetch(); with
+	// no hosts listed, fetch is disabled entirely.
+	FetchAllowlist []string `json:"fetchAllowlist,omitempty" yaml:"fetchAllowlist,omitempty"`
+	// StateFile optionally persists the state.get/state.set KV store
+	// across restarts.
+	StateFile string `json:"stateFile,omitempty" yaml:"stateFile,omitempty"`
 }
 
 type OpenAPIInfo struct {
-	Title       string `json:"title"`
-	Description string `json:"description,omitempty"`
-	Version     string `json:"version"`
+	Title       string `json:"title" yaml:"title"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	Version     string `json:"version" yaml:"version"`
 }
 
-// JSRuntime manages a pool of goja VMs for script execution
+// defaultScriptTimeoutMs is used when an endpoint doesn't set scriptTimeoutMs.
+const defaultScriptTimeoutMs = 5000
+
+// maxScriptOutputBytes bounds the size of a script's JSON-encoded response
+// body, regardless of endpoint.
+const maxScriptOutputBytes = 1 << 20 // 1MB
+
+// JSRuntime manages a pool of goja VMs for script execution, plus the
+// sandboxing primitives (timeouts, fetch allowlist, shared state) exposed
+// to every script.
 type JSRuntime struct {
 	pool sync.Pool
+
+	store          *KVStore
+	httpClient     *http.Client
+	fetchAllowlist map[string]bool
 }
 
 // RequestData contains all request information passed to scripts
@@ -60,17 +109,36 @@ type ScriptResult struct {
 	Headers    map[string]string `json:"headers,omitempty"`
 }
 
-func NewJSRuntime() *JSRuntime {
+func NewJSRuntime(store *KVStore, fetchAllowlist []string) *JSRuntime {
+	allowlist := make(map[string]bool, len(fetchAllowlist))
+	for _, host := range fetchAllowlist {
+		allowlist[host] = true
+	}
 	return &JSRuntime{
 		pool: sync.Pool{
 			New: func() any {
-				return goja.New()
+				vm := goja.New()
+				// Scripts call the documented lowercase API (req.params,
+				// res.status(...)), but goja's default field mapper
+				// exposes exported Go struct/method names verbatim
+				// (Params, Status); uncap it so they match.
+				vm.SetFieldNameMapper(goja.UncapFieldNameMapper())
+				return vm
 			},
 		},
+		store:          store,
+		httpClient:     &http.Client{Timeout: 10 * time.Second},
+		fetchAllowlist: allowlist,
 	}
 }
 
-func (jr *JSRuntime) Execute(script string, req RequestData, context map[string]any) (*ScriptResult, error) {
+// Execute runs script on a VM taken from the pool, with the built-in
+// globals, req/request and context set afresh (other globals left on that
+// VM by an earlier script are not cleared), within a hard wall-clock
+// timeout (timeoutMs, or defaultScriptTimeoutMs if <= 0), recovering from
+// any panic in user script evaluation and returning it as an error instead
+// of crashing the handler.
+func (jr *JSRuntime) Execute(script string, req RequestData, context map[string]any, timeoutMs int) (result *ScriptResult, err error) {
 	vm := jr.pool.Get().(*goja.Runtime)
 	defer func() {
 		// Clear the VM state before returning to pool
@@ -78,6 +146,21 @@ func (jr *JSRuntime) Execute(script string, req RequestData, context map[string]
 		jr.pool.Put(vm)
 	}()
 
+	defer func() {
+		if p := recover(); p != nil {
+			result = nil
+			err = fmt.Errorf("script panicked: %v", p)
+		}
+	}()
+
+	if timeoutMs <= 0 {
+		timeoutMs = defaultScriptTimeoutMs
+	}
+	timer := time.AfterFunc(time.Duration(timeoutMs)*time.Millisecond, func() {
+		vm.Interrupt(fmt.Sprintf("script execution timed out after %dms", timeoutMs))
+	})
+	defer timer.Stop()
+
 	// Set up built-in functions
 	vm.Set("console", map[string]any{
 		"log": func(args ...any) {
@@ -95,6 +178,19 @@ func (jr *JSRuntime) Execute(script string, req RequestData, context map[string]
 	vm.Set("timestamp", func() int64 {
 		return time.Now().Unix()
 	})
+	vm.Set("fetch", jr.jsFetch)
+	vm.Set("state", map[string]any{
+		"get": jr.store.Get,
+		"set": jr.store.Set,
+	})
+	vm.Set("random", map[string]any{
+		"int":  jsRandomInt,
+		"pick": jsRandomPick,
+	})
+	vm.Set("faker", map[string]any{
+		"name": fakerName,
+	})
+	vm.Set("res", newResBuilder())
 
 	// Set request data
 	vm.Set("req", req)
@@ -106,13 +202,13 @@ func (jr *JSRuntime) Execute(script string, req RequestData, context map[string]
 	}
 
 	// Execute the script
-	val, err := vm.RunString(script)
-	if err != nil {
-		return nil, err
+	val, runErr := vm.RunString(script)
+	if runErr != nil {
+		return nil, runErr
 	}
 
 	// Handle the result
-	result := &ScriptResult{StatusCode: 200}
+	result = &ScriptResult{StatusCode: 200}
 
 	if val == nil || goja.IsUndefined(val) || goja.IsNull(val) {
 		result.Body = nil
@@ -126,15 +222,21 @@ func (jr *JSRuntime) Execute(script string, req RequestData, context map[string]
 		if body, exists := m["body"]; exists {
 			result.Body = body
 			if sc, exists := m["statusCode"]; exists {
-				if code, ok := sc.(int64); ok {
+				switch code := sc.(type) {
+				case int:
+					result.StatusCode = code
+				case int64:
 					result.StatusCode = int(code)
-				} else if code, ok := sc.(float64); ok {
+				case float64:
 					result.StatusCode = int(code)
 				}
 			}
 			if headers, exists := m["headers"]; exists {
-				if h, ok := headers.(map[string]any); ok {
-					result.Headers = make(map[string]string)
+				switch h := headers.(type) {
+				case map[string]string:
+					result.Headers = h
+				case map[string]any:
+					result.Headers = make(map[string]string, len(h))
 					for k, v := range h {
 						if s, ok := v.(string); ok {
 							result.Headers[k] = s
@@ -142,12 +244,17 @@ func (jr *JSRuntime) Execute(script string, req RequestData, context map[string]
 					}
 				}
 			}
-			return result, nil
+		} else {
+			result.Body = exported
 		}
+	} else {
+		result.Body = exported
+	}
+
+	if bodyBytes, marshalErr := json.Marshal(result.Body); marshalErr == nil && len(bodyBytes) > maxScriptOutputBytes {
+		return nil, fmt.Errorf("script output of %d bytes exceeds max body size of %d bytes", len(bodyBytes), maxScriptOutputBytes)
 	}
 
-	// Otherwise, use the entire result as the body
-	result.Body = exported
 	return result, nil
 }
 
@@ -222,6 +329,12 @@ func generateOpenAPISpec(config *Config) map[string]any {
 	paths := make(map[string]any)
 
 	for _, ep := range config.Endpoints {
+		if ep.Protocol == "grpc" {
+			// Served from the separate gRPC server, not the HTTP mux;
+			// it has no place in an HTTP OpenAPI spec.
+			continue
+		}
+
 		method := strings.ToLower(ep.Method)
 		if method == "" {
 			method = "get"
@@ -271,6 +384,22 @@ func generateOpenAPISpec(config *Config) map[string]any {
 		if len(ep.Tags) > 0 {
 			operation["tags"] = ep.Tags
 		}
+		if ep.Proxy != nil {
+			operation["x-stub-proxy"] = map[string]any{
+				"target": ep.Proxy.Target,
+				"mode":   ep.Proxy.Mode,
+			}
+		}
+		if ep.Script != "" {
+			operation["x-stub-script-api"] = scriptAPIDoc
+		}
+		if ep.Protocol == "ws" {
+			wsDoc := map[string]any{"scripted": ep.WS != nil && ep.WS.Script != ""}
+			if ep.WS != nil && len(ep.WS.Steps) > 0 {
+				wsDoc["steps"] = len(ep.WS.Steps)
+			}
+			operation["x-stub-websocket"] = wsDoc
+		}
 
 		if _, exists := paths[ep.Path]; !exists {
 			paths[ep.Path] = make(map[string]any)
@@ -295,6 +424,22 @@ func generateOpenAPISpec(config *Config) map[string]any {
 			},
 		},
 	}
+	paths["/metrics"] = map[string]any{
+		"get": map[string]any{
+			"summary": "Prometheus metrics",
+			"tags":    []string{"System"},
+			"responses": map[string]any{
+				"200": map[string]any{
+					"description": "Metrics in Prometheus text exposition format",
+					"content": map[string]any{
+						"text/plain": map[string]any{
+							"example": "stubber_requests_total{method=\"GET\",path=\"/users/{id}\"} 1",
+						},
+					},
+				},
+			},
+		},
+	}
 
 	return map[string]any{
 		"openapi": "3.0.3",
@@ -327,157 +472,82 @@ func statusCodeToString(code int) string {
 }
 
 func main() {
+	logFormat := flag.String("log-format", "text", "request log format: text or json")
+	flag.Parse()
+
 	configPath := os.Getenv("CONFIG_PATH")
 	if configPath == "" {
 		configPath = "/config/endpoints.json"
 	}
 
-	data, err := os.ReadFile(configPath)
+	bootstrapConfig, err := loadConfig(configPath)
 	if err != nil {
-		log.Fatalf("Failed to read config: %v", err)
+		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		log.Fatalf("Failed to parse config: %v", err)
+	deps := &serverDeps{
+		jsRuntime:     NewJSRuntime(NewKVStore(bootstrapConfig.StateFile), bootstrapConfig.FetchAllowlist),
+		metrics:       NewMetricsRegistry(),
+		requestLogger: NewRequestLogger(*logFormat),
+		proxyHandler:  NewProxyHandler(),
 	}
 
-	// Initialize JS runtime for scripted endpoints
-	jsRuntime := NewJSRuntime()
+	rl, err := newReloader(configPath, deps)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if err := rl.watch(); err != nil {
+		log.Printf("Config watch disabled: %v", err)
+	}
 
-	// Generate OpenAPI spec
-	openAPISpec := generateOpenAPISpec(&config)
+	// gRPC endpoints are resolved once from the bootstrap config and served
+	// from a separate server; they are not affected by config hot-reload.
+	grpcAddr := os.Getenv("GRPC_ADDR")
+	if grpcAddr == "" {
+		grpcAddr = ":9090"
+	}
+	if _, err := startGRPCServer(grpcAddr, bootstrapConfig.Endpoints, deps); err != nil {
+		log.Printf("gRPC server disabled: %v", err)
+	}
 
 	// Serve OpenAPI spec
 	http.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(openAPISpec)
+		json.NewEncoder(w).Encode(rl.current().openAPISpec)
 	})
 	log.Println("Registered: GET /openapi.json")
 
-	// Group endpoints by path
-	pathEndpoints := make(map[string][]Endpoint)
-	for _, ep := range config.Endpoints {
-		pathEndpoints[ep.Path] = append(pathEndpoints[ep.Path], ep)
-	}
-
-	for path, endpoints := range pathEndpoints {
-		eps := endpoints // capture for closure
-		pathPattern := path
-		http.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
-			// Find matching endpoint for this method
-			var endpoint *Endpoint
-			for i := range eps {
-				if eps[i].Method == "" || eps[i].Method == r.Method {
-					endpoint = &eps[i]
-					break
-				}
-			}
-
-			if endpoint == nil {
-				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-				return
-			}
-
-			// Set headers from config
-			for k, v := range endpoint.Headers {
-				w.Header().Set(k, v)
-			}
-			if w.Header().Get("Content-Type") == "" {
-				w.Header().Set("Content-Type", "application/json")
-			}
-
-			// Check if this is a scripted endpoint
-			if endpoint.Script != "" {
-				// Build request data for the script
-				reqData := RequestData{
-					Method:  r.Method,
-					Path:    r.URL.Path,
-					Query:   make(map[string]string),
-					Headers: make(map[string]string),
-					Params:  extractPathValues(pathPattern, r.URL.Path),
-				}
-
-				// Extract query parameters
-				for key, values := range r.URL.Query() {
-					if len(values) > 0 {
-						reqData.Query[key] = values[0]
-					}
-				}
-
-				// Extract headers
-				for key, values := range r.Header {
-					if len(values) > 0 {
-						reqData.Headers[key] = values[0]
-					}
-				}
-
-				// Parse body if present
-				if r.Body != nil {
-					bodyBytes, err := io.ReadAll(r.Body)
-					if err == nil && len(bodyBytes) > 0 {
-						var bodyData any
-						if json.Unmarshal(bodyBytes, &bodyData) == nil {
-							reqData.Body = bodyData
-						} else {
-							reqData.Body = string(bodyBytes)
-						}
-					}
-				}
-
-				// Execute the script
-				result, err := jsRuntime.Execute(endpoint.Script, reqData, endpoint.Context)
-				if err != nil {
-					log.Printf("Script error for %s %s: %v", r.Method, r.URL.Path, err)
-					w.WriteHeader(http.StatusInternalServerError)
-					json.NewEncoder(w).Encode(map[string]string{
-						"error":   "Script execution failed",
-						"details": err.Error(),
-					})
-					return
-				}
-
-				// Set response headers from script result
-				for k, v := range result.Headers {
-					w.Header().Set(k, v)
-				}
-
-				// Use script's status code or endpoint's or default to 200
-				statusCode := result.StatusCode
-				if statusCode == 0 {
-					statusCode = endpoint.StatusCode
-				}
-				if statusCode == 0 {
-					statusCode = 200
-				}
-				w.WriteHeader(statusCode)
-
-				json.NewEncoder(w).Encode(result.Body)
-				return
-			}
+	// Serve Prometheus metrics
+	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		deps.metrics.WritePrometheus(w)
+	})
+	log.Println("Registered: GET /metrics")
 
-			// Static response (no script)
-			statusCode := endpoint.StatusCode
-			if statusCode == 0 {
-				statusCode = 200
-			}
-			w.WriteHeader(statusCode)
-
-			json.NewEncoder(w).Encode(endpoint.Response)
-		})
-		for _, ep := range eps {
-			scriptIndicator := ""
-			if ep.Script != "" {
-				scriptIndicator = " [scripted]"
-			}
-			log.Printf("Registered: %s %s%s", ep.Method, ep.Path, scriptIndicator)
+	// Manual reload trigger
+	http.HandleFunc("/admin/reload", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
 		}
-	}
+		if err := rl.reload(); err != nil {
+			http.Error(w, "Reload failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte("reloaded"))
+	})
+	log.Println("Registered: POST /admin/reload")
 
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("ok"))
 	})
 
+	// All endpoint paths are served from the current serverState's mux, so
+	// a reload can swap endpoints without re-registering on DefaultServeMux.
+	http.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rl.current().mux.ServeHTTP(w, r)
+	}))
+
 	log.Println("Server starting on :8080")
 	log.Fatal(http.ListenAndServe(":8080", nil))
 }
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBuckets are the histogram bucket boundaries, in seconds.
+var latencyBuckets = []float64{0.001, 0.005, 0.025, 0.1, 0.5, 2.5}
+
+// endpointMetrics holds the counters and latency histogram for a single
+// method+path combination.
+type endpointMetrics struct {
+	requestsTotal       uint64
+	errorsTotal         uint64
+	scriptFailuresTotal uint64
+	inFlight            int64
+
+	mu           sync.Mutex
+	bucketCounts []uint64 // cumulative counts, one per latencyBuckets entry plus a trailing +Inf bucket
+	sum          float64
+	count        uint64
+}
+
+func newEndpointMetrics() *endpointMetrics {
+	return &endpointMetrics{bucketCounts: make([]uint64, len(latencyBuckets)+1)}
+}
+
+func (m *endpointMetrics) observe(statusCode int, scriptFailed bool, duration time.Duration) {
+	atomic.AddUint64(&m.requestsTotal, 1)
+	if statusCode >= 400 {
+		atomic.AddUint64(&m.errorsTotal, 1)
+	}
+	if scriptFailed {
+		atomic.AddUint64(&m.scriptFailuresTotal, 1)
+	}
+
+	seconds := duration.Seconds()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sum += seconds
+	m.count++
+	for i, bound := range latencyBuckets {
+		if seconds <= bound {
+			m.bucketCounts[i]++
+		}
+	}
+	m.bucketCounts[len(latencyBuckets)]++ // +Inf always counts
+}
+
+// MetricsRegistry tracks per-endpoint request metrics and renders them in
+// Prometheus text exposition format.
+type MetricsRegistry struct {
+	mu        sync.Mutex
+	endpoints map[string]*endpointMetrics
+}
+
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{endpoints: make(map[string]*endpointMetrics)}
+}
+
+type metricsKey struct {
+	method string
+	path   string
+}
+
+func (r *MetricsRegistry) endpointFor(method, path string) *endpointMetrics {
+	key := method + " " + path
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m, ok := r.endpoints[key]
+	if !ok {
+		m = newEndpointMetrics()
+		r.endpoints[key] = m
+	}
+	return m
+}
+
+// BeginRequest marks an in-flight request and returns a func that must be
+// called (typically via defer) once the request completes.
+func (r *MetricsRegistry) BeginRequest(method, path string) func(statusCode int, scriptFailed bool) {
+	m := r.endpointFor(method, path)
+	atomic.AddInt64(&m.inFlight, 1)
+	start := time.Now()
+	return func(statusCode int, scriptFailed bool) {
+		atomic.AddInt64(&m.inFlight, -1)
+		m.observe(statusCode, scriptFailed, time.Since(start))
+	}
+}
+
+func (r *MetricsRegistry) sortedKeys() []metricsKey {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := make([]metricsKey, 0, len(r.endpoints))
+	for key := range r.endpoints {
+		for i := 0; i < len(key); i++ {
+			if key[i] == ' ' {
+				keys = append(keys, metricsKey{method: key[:i], path: key[i+1:]})
+				break
+			}
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].path != keys[j].path {
+			return keys[i].path < keys[j].path
+		}
+		return keys[i].method < keys[j].method
+	})
+	return keys
+}
+
+// WritePrometheus renders all tracked metrics in Prometheus text exposition
+// format.
+func (r *MetricsRegistry) WritePrometheus(w io.Writer) {
+	keys := r.sortedKeys()
+
+	fmt.Fprintln(w, "# HELP stubber_requests_total Total number of requests handled per endpoint.")
+	fmt.Fprintln(w, "# TYPE stubber_requests_total counter")
+	for _, k := range keys {
+		m := r.endpointFor(k.method, k.path)
+		fmt.Fprintf(w, "stubber_requests_total{method=%q,path=%q} %d\n", k.method, k.path, atomic.LoadUint64(&m.requestsTotal))
+	}
+
+	fmt.Fprintln(w, "# HELP stubber_errors_total Total number of responses with a status code >= 400.")
+	fmt.Fprintln(w, "# TYPE stubber_errors_total counter")
+	for _, k := range keys {
+		m := r.endpointFor(k.method, k.path)
+		fmt.Fprintf(w, "stubber_errors_total{method=%q,path=%q} %d\n", k.method, k.path, atomic.LoadUint64(&m.errorsTotal))
+	}
+
+	fmt.Fprintln(w, "# HELP stubber_script_failures_total Total number of scripted endpoint executions that returned an error.")
+	fmt.Fprintln(w, "# TYPE stubber_script_failures_total counter")
+	for _, k := range keys {
+		m := r.endpointFor(k.method, k.path)
+		fmt.Fprintf(w, "stubber_script_failures_total{method=%q,path=%q} %d\n", k.method, k.path, atomic.LoadUint64(&m.scriptFailuresTotal))
+	}
+
+	fmt.Fprintln(w, "# HELP stubber_in_flight_requests Number of requests currently being handled per endpoint.")
+	fmt.Fprintln(w, "# TYPE stubber_in_flight_requests gauge")
+	for _, k := range keys {
+		m := r.endpointFor(k.method, k.path)
+		fmt.Fprintf(w, "stubber_in_flight_requests{method=%q,path=%q} %d\n", k.method, k.path, atomic.LoadInt64(&m.inFlight))
+	}
+
+	fmt.Fprintln(w, "# HELP stubber_request_duration_seconds Histogram of request latency in seconds.")
+	fmt.Fprintln(w, "# TYPE stubber_request_duration_seconds histogram")
+	for _, k := range keys {
+		m := r.endpointFor(k.method, k.path)
+		m.mu.Lock()
+		for i, bound := range latencyBuckets {
+			fmt.Fprintf(w, "stubber_request_duration_seconds_bucket{method=%q,path=%q,le=\"%g\"} %d\n", k.method, k.path, bound, m.bucketCounts[i])
+		}
+		fmt.Fprintf(w, "stubber_request_duration_seconds_bucket{method=%q,path=%q,le=\"+Inf\"} %d\n", k.method, k.path, m.bucketCounts[len(latencyBuckets)])
+		fmt.Fprintf(w, "stubber_request_duration_seconds_sum{method=%q,path=%q} %g\n", k.method, k.path, m.sum)
+		fmt.Fprintf(w, "stubber_request_duration_seconds_count{method=%q,path=%q} %d\n", k.method, k.path, m.count)
+		m.mu.Unlock()
+	}
+}
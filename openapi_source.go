@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+)
+
+// loadExternalOpenAPI loads the spec referenced by Config.OpenAPI, if any.
+// The field may hold a file path (string) or an inline spec object.
+func loadExternalOpenAPI(cfg *Config) (*openapi3.T, error) {
+	if cfg.OpenAPI == nil {
+		return nil, nil
+	}
+
+	loader := openapi3.NewLoader()
+	loader.IsExternalRefsAllowed = true
+
+	if path, ok := cfg.OpenAPI.(string); ok {
+		return loader.LoadFromFile(path)
+	}
+
+	data, err := json.Marshal(cfg.OpenAPI)
+	if err != nil {
+		return nil, err
+	}
+	return loader.LoadFromData(data)
+}
+
+// mergeOpenAPIEndpoints augments cfg.Endpoints with one generated endpoint
+// per operation in doc that isn't already explicitly configured, and wires
+// specOp onto every endpoint (generated or explicit) that has a matching
+// operation so it can be validated against the spec.
+func mergeOpenAPIEndpoints(cfg *Config, doc *openapi3.T) {
+	existing := make(map[string]int, len(cfg.Endpoints))
+	for i, ep := range cfg.Endpoints {
+		existing[endpointKey(ep.Method, ep.Path)] = i
+	}
+
+	for path, item := range doc.Paths {
+		for _, mo := range pathItemOperations(item) {
+			key := endpointKey(mo.method, path)
+			if idx, ok := existing[key]; ok {
+				cfg.Endpoints[idx].specOp = mo.op
+				continue
+			}
+			cfg.Endpoints = append(cfg.Endpoints, Endpoint{
+				Path:        path,
+				Method:      mo.method,
+				StatusCode:  firstSuccessStatus(mo.op),
+				Response:    synthesizeResponse(mo.op),
+				Summary:     mo.op.Summary,
+				Description: mo.op.Description,
+				Tags:        mo.op.Tags,
+				specOp:      mo.op,
+			})
+		}
+	}
+}
+
+func endpointKey(method, path string) string {
+	if method == "" {
+		method = "GET"
+	}
+	return strings.ToUpper(method) + " " + path
+}
+
+type methodOperation struct {
+	method string
+	op     *openapi3.Operation
+}
+
+// pathItemOperations enumerates the standard HTTP methods present on a
+// PathItem, since openapi3.PathItem exposes them as named fields rather
+// than a map.
+func pathItemOperations(item *openapi3.PathItem) []methodOperation {
+	var ops []methodOperation
+	add := func(method string, op *openapi3.Operation) {
+		if op != nil {
+			ops = append(ops, methodOperation{method: method, op: op})
+		}
+	}
+	add("GET", item.Get)
+	add("POST", item.Post)
+	add("PUT", item.Put)
+	add("PATCH", item.Patch)
+	add("DELETE", item.Delete)
+	add("HEAD", item.Head)
+	add("OPTIONS", item.Options)
+	return ops
+}
+
+func firstSuccessStatus(op *openapi3.Operation) int {
+	for _, code := range []string{"200", "201", "204"} {
+		if _, ok := op.Responses[code]; ok {
+			return statusCodeFromString(code)
+		}
+	}
+	return 200
+}
+
+func statusCodeFromString(code string) int {
+	switch code {
+	case "201":
+		return 201
+	case "204":
+		return 204
+	default:
+		return 200
+	}
+}
+
+// firstSuccessResponse picks the response to synthesize an example from:
+// 200, then 201/204, then any other 2xx, then "default".
+func firstSuccessResponse(responses openapi3.Responses) *openapi3.ResponseRef {
+	for _, code := range []string{"200", "201", "204"} {
+		if ref, ok := responses[code]; ok {
+			return ref
+		}
+	}
+	for code, ref := range responses {
+		if strings.HasPrefix(code, "2") {
+			return ref
+		}
+	}
+	return responses["default"]
+}
+
+// synthesizeResponse derives a stub response body from an operation's
+// declared example, or failing that, its schema.
+func synthesizeResponse(op *openapi3.Operation) any {
+	if op.Responses == nil {
+		return nil
+	}
+	ref := firstSuccessResponse(op.Responses)
+	if ref == nil || ref.Value == nil {
+		return nil
+	}
+
+	for _, media := range ref.Value.Content {
+		if media.Example != nil {
+			return media.Example
+		}
+		for _, ex := range media.Examples {
+			if ex.Value != nil {
+				return ex.Value.Value
+			}
+		}
+		if media.Schema != nil && media.Schema.Value != nil {
+			return synthesizeFromSchema(media.Schema.Value)
+		}
+	}
+	return nil
+}
+
+// synthesizeFromSchema builds a zero-ish value matching schema's declared
+// type, used when an operation has no example to fall back on.
+func synthesizeFromSchema(schema *openapi3.Schema) any {
+	if schema.Example != nil {
+		return schema.Example
+	}
+	if len(schema.Enum) > 0 {
+		return schema.Enum[0]
+	}
+
+	switch schema.Type {
+	case "object":
+		obj := make(map[string]any, len(schema.Properties))
+		for name, propRef := range schema.Properties {
+			if propRef.Value != nil {
+				obj[name] = synthesizeFromSchema(propRef.Value)
+			}
+		}
+		return obj
+	case "array":
+		if schema.Items != nil && schema.Items.Value != nil {
+			return []any{synthesizeFromSchema(schema.Items.Value)}
+		}
+		return []any{}
+	case "integer":
+		return 0
+	case "number":
+		return 0.0
+	case "boolean":
+		return false
+	default:
+		if len(schema.Properties) > 0 {
+			obj := make(map[string]any, len(schema.Properties))
+			for name, propRef := range schema.Properties {
+				if propRef.Value != nil {
+					obj[name] = synthesizeFromSchema(propRef.Value)
+				}
+			}
+			return obj
+		}
+		return ""
+	}
+}
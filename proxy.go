@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProxyConfig describes an endpoint that forwards to (and optionally
+// records from, or replays against) an upstream target instead of
+// returning a static or scripted response.
+type ProxyConfig struct {
+	Target string `json:"target" yaml:"target"`
+	Record string `json:"record,omitempty" yaml:"record,omitempty"`
+	Mode   string `json:"mode,omitempty" yaml:"mode,omitempty"` // passthrough|record|replay, defaults to passthrough
+}
+
+// fixtureEntry is one recorded request/response pair.
+type fixtureEntry struct {
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	BodyHash   string            `json:"bodyHash,omitempty"`
+	StatusCode int               `json:"statusCode"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       json.RawMessage   `json:"body"`
+}
+
+// fixtureFile is the on-disk record/replay store for a single proxy
+// endpoint's `record` file, shared across requests.
+type fixtureFile struct {
+	mu      sync.Mutex
+	path    string
+	entries []fixtureEntry
+}
+
+func loadFixtureFile(path string) *fixtureFile {
+	f := &fixtureFile{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return f
+	}
+	_ = json.Unmarshal(data, &f.entries)
+	return f
+}
+
+func (f *fixtureFile) add(entry fixtureEntry) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.entries = append(f.entries, entry)
+	data, err := json.MarshalIndent(f.entries, "", "  ")
+	if err != nil {
+		return
+	}
+	_ = os.WriteFile(f.path, data, 0o644)
+}
+
+// match finds the best matching recorded fixture for a request, preferring
+// an exact body-hash match and falling back to the first entry with the
+// same method+path that was recorded without a body hash.
+func (f *fixtureFile) match(method, path, bodyHash string) (fixtureEntry, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var fallback *fixtureEntry
+	for i := range f.entries {
+		e := &f.entries[i]
+		if e.Method != method || e.Path != path {
+			continue
+		}
+		if e.BodyHash == bodyHash {
+			return *e, true
+		}
+		if e.BodyHash == "" && fallback == nil {
+			fallback = e
+		}
+	}
+	if fallback != nil {
+		return *fallback, true
+	}
+	return fixtureEntry{}, false
+}
+
+func hashBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// ProxyHandler forwards requests to upstream targets, optionally recording
+// or replaying them against a fixture file.
+type ProxyHandler struct {
+	client *http.Client
+
+	mu     sync.Mutex
+	stores map[string]*fixtureFile
+}
+
+func NewProxyHandler() *ProxyHandler {
+	return &ProxyHandler{
+		client: &http.Client{Timeout: 30 * time.Second},
+		stores: make(map[string]*fixtureFile),
+	}
+}
+
+func (p *ProxyHandler) storeFor(record string) *fixtureFile {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	store, ok := p.stores[record]
+	if !ok {
+		store = loadFixtureFile(record)
+		p.stores[record] = store
+	}
+	return store
+}
+
+// Handle dispatches a request through the endpoint's configured proxy mode.
+func (p *ProxyHandler) Handle(cfg *ProxyConfig, endpointPath string, w http.ResponseWriter, r *http.Request) {
+	mode := cfg.Mode
+	if mode == "" {
+		mode = "passthrough"
+	}
+
+	switch mode {
+	case "replay":
+		p.replay(cfg, w, r)
+	case "record":
+		p.forward(cfg, endpointPath, w, r, true)
+	default:
+		p.forward(cfg, endpointPath, w, r, false)
+	}
+}
+
+func (p *ProxyHandler) forward(cfg *ProxyConfig, endpointPath string, w http.ResponseWriter, r *http.Request, record bool) {
+	targetURL, err := buildProxyURL(cfg.Target, endpointPath, r.URL.Path, r.URL.RawQuery)
+	if err != nil {
+		http.Error(w, "invalid proxy target: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	var reqBody []byte
+	if r.Body != nil {
+		reqBody, _ = io.ReadAll(r.Body)
+	}
+
+	upstreamReq, err := http.NewRequest(r.Method, targetURL, bytes.NewReader(reqBody))
+	if err != nil {
+		http.Error(w, "failed to build upstream request: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	copyHeaders(upstreamReq.Header, r.Header)
+
+	resp, err := p.client.Do(upstreamReq)
+	if err != nil {
+		http.Error(w, "upstream request failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		http.Error(w, "failed to read upstream response: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	copyHeaders(w.Header(), resp.Header)
+	w.WriteHeader(resp.StatusCode)
+	w.Write(respBody)
+
+	if record {
+		p.storeFor(cfg.Record).add(fixtureEntry{
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			BodyHash:   hashBody(reqBody),
+			StatusCode: resp.StatusCode,
+			Headers:    flattenHeaders(resp.Header),
+			Body:       json.RawMessage(respBody),
+		})
+	}
+}
+
+func (p *ProxyHandler) replay(cfg *ProxyConfig, w http.ResponseWriter, r *http.Request) {
+	var reqBody []byte
+	if r.Body != nil {
+		reqBody, _ = io.ReadAll(r.Body)
+	}
+
+	entry, ok := p.storeFor(cfg.Record).match(r.Method, r.URL.Path, hashBody(reqBody))
+	if !ok {
+		http.Error(w, "no recorded fixture for "+r.Method+" "+r.URL.Path, http.StatusNotFound)
+		return
+	}
+
+	for k, v := range entry.Headers {
+		w.Header().Set(k, v)
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(entry.StatusCode)
+	w.Write(entry.Body)
+}
+
+// buildProxyURL rewrites a request path into the proxy target, preserving
+// whatever suffix follows the endpoint's own (possibly templated) path.
+func buildProxyURL(target, endpointPath, requestPath, rawQuery string) (string, error) {
+	// Skip as many request segments as the endpoint path has, so a
+	// "{param}" segment consumes whatever value the request carries there.
+	prefixLen := len(strings.Split(strings.TrimSuffix(endpointPath, "/"), "/"))
+	requestSegments := strings.Split(requestPath, "/")
+	suffix := ""
+	if len(requestSegments) > prefixLen {
+		suffix = "/" + strings.Join(requestSegments[prefixLen:], "/")
+	}
+	u, err := url.Parse(strings.TrimRight(target, "/") + suffix)
+	if err != nil {
+		return "", err
+	}
+	u.RawQuery = rawQuery
+	return u.String(), nil
+}
+
+func copyHeaders(dst, src http.Header) {
+	for k, values := range src {
+		for _, v := range values {
+			dst.Add(k, v)
+		}
+	}
+}
+
+func flattenHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, values := range h {
+		if len(values) > 0 {
+			out[k] = values[0]
+		}
+	}
+	return out
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFixtureFileMatch(t *testing.T) {
+	f := &fixtureFile{
+		entries: []fixtureEntry{
+			{Method: "POST", Path: "/users", BodyHash: "abc", StatusCode: 201},
+			{Method: "GET", Path: "/users", BodyHash: "", StatusCode: 200},
+			{Method: "GET", Path: "/orders", BodyHash: "", StatusCode: 200},
+		},
+	}
+
+	t.Run("exact body hash match wins", func(t *testing.T) {
+		entry, ok := f.match("POST", "/users", "abc")
+		if !ok || entry.StatusCode != 201 {
+			t.Fatalf("match(POST, /users, abc) = %+v, %v; want 201, true", entry, ok)
+		}
+	})
+
+	t.Run("falls back to method+path when no body hash recorded", func(t *testing.T) {
+		entry, ok := f.match("GET", "/users", "some-other-hash")
+		if !ok || entry.StatusCode != 200 {
+			t.Fatalf("match(GET, /users, ...) = %+v, %v; want 200, true", entry, ok)
+		}
+	})
+
+	t.Run("no match for unknown method+path", func(t *testing.T) {
+		if _, ok := f.match("DELETE", "/users", ""); ok {
+			t.Fatal("match(DELETE, /users) = true; want false")
+		}
+	})
+
+	t.Run("body hash mismatch with no fallback entry does not match", func(t *testing.T) {
+		if _, ok := f.match("POST", "/users", "different"); ok {
+			t.Fatal("match(POST, /users, different) = true; want false")
+		}
+	})
+}
+
+func TestFixtureFileAddPersistsAndReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+
+	f := loadFixtureFile(path)
+	f.add(fixtureEntry{Method: "GET", Path: "/ping", StatusCode: 200})
+
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("fixture file was not written: %v", err)
+	}
+
+	reloaded := loadFixtureFile(path)
+	entry, ok := reloaded.match("GET", "/ping", "")
+	if !ok || entry.StatusCode != 200 {
+		t.Fatalf("reloaded match(GET, /ping) = %+v, %v; want 200, true", entry, ok)
+	}
+}
+
+func TestBuildProxyURL(t *testing.T) {
+	tests := []struct {
+		name         string
+		target       string
+		endpointPath string
+		requestPath  string
+		rawQuery     string
+		want         string
+	}{
+		{
+			name:         "exact match has no suffix",
+			target:       "http://upstream:8080",
+			endpointPath: "/users",
+			requestPath:  "/users",
+			want:         "http://upstream:8080",
+		},
+		{
+			name:         "suffix after endpoint path is preserved",
+			target:       "http://upstream:8080/api",
+			endpointPath: "/users",
+			requestPath:  "/users/42",
+			want:         "http://upstream:8080/api/42",
+		},
+		{
+			name:         "query string is carried over",
+			target:       "http://upstream:8080",
+			endpointPath: "/users",
+			requestPath:  "/users",
+			rawQuery:     "page=2",
+			want:         "http://upstream:8080?page=2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := buildProxyURL(tt.target, tt.endpointPath, tt.requestPath, tt.rawQuery)
+			if err != nil {
+				t.Fatalf("buildProxyURL returned error: %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("buildProxyURL() = %q; want %q", got, tt.want)
+			}
+		})
+	}
+}
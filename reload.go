@@ -0,0 +1,118 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// reloader owns the current serverState and knows how to rebuild it from
+// disk on demand (manual trigger or file watch).
+type reloader struct {
+	configPath string
+	deps       *serverDeps
+	state      atomic.Pointer[serverState]
+}
+
+func newReloader(configPath string, deps *serverDeps) (*reloader, error) {
+	r := &reloader{configPath: configPath, deps: deps}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *reloader) current() *serverState {
+	return r.state.Load()
+}
+
+// reload reparses the config from disk, builds a fresh serverState, logs
+// what changed relative to the previous state, and swaps it in. In-flight
+// requests continue to be served by the serverState they started with.
+func (r *reloader) reload() error {
+	cfg, err := loadConfig(r.configPath)
+	if err != nil {
+		return err
+	}
+
+	next := buildServerState(cfg, r.deps)
+
+	if prev := r.state.Load(); prev != nil {
+		added, removed, changed := diffEndpoints(prev.config, cfg)
+		for _, k := range added {
+			log.Printf("reload: + %s", k)
+		}
+		for _, k := range removed {
+			log.Printf("reload: - %s", k)
+		}
+		for _, k := range changed {
+			log.Printf("reload: ~ %s", k)
+		}
+		if len(added)+len(removed)+len(changed) == 0 {
+			log.Println("reload: no endpoint changes")
+		}
+	}
+
+	r.state.Store(next)
+	return nil
+}
+
+// watch watches configPath (a file, directory, or the directory containing
+// a glob pattern) for changes and triggers a debounced reload whenever a
+// config file is created, written, removed, or renamed.
+func (r *reloader) watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	dir := r.configPath
+	if !isDir(dir) {
+		dir = filepath.Dir(dir)
+	}
+	if err := watcher.Add(dir); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		var debounce *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !isConfigFile(event.Name) {
+					continue
+				}
+				if debounce != nil {
+					debounce.Stop()
+				}
+				debounce = time.AfterFunc(200*time.Millisecond, func() {
+					if err := r.reload(); err != nil {
+						log.Printf("reload failed: %v", err)
+					}
+				})
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("config watcher error: %v", err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
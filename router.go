@@ -0,0 +1,85 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// patternMux dispatches requests to handlers registered against path
+// patterns containing "{param}" segments (e.g. "/users/{id}"). The
+// standard http.ServeMux on Go 1.21 (this module's toolchain) only does
+// exact-literal matching, which can't route a parameterized pattern to an
+// actual request path, so endpoints are routed here instead.
+type patternMux struct {
+	routes []patternRoute
+}
+
+type patternRoute struct {
+	segments []string
+	handler  http.HandlerFunc
+}
+
+// HandleFunc registers handler against pattern, split into segments once
+// up front so matching a request doesn't re-split the pattern every time.
+func (m *patternMux) HandleFunc(pattern string, handler http.HandlerFunc) {
+	m.routes = append(m.routes, patternRoute{
+		segments: strings.Split(pattern, "/"),
+		handler:  handler,
+	})
+}
+
+// ServeHTTP implements http.Handler so a *patternMux can be used wherever
+// serverState.mux was previously an *http.ServeMux.
+func (m *patternMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	handler := m.match(r.URL.Path)
+	if handler == nil {
+		http.NotFound(w, r)
+		return
+	}
+	handler(w, r)
+}
+
+// match finds the best route for path: the one with the fewest "{param}"
+// segments, so a literal registration (e.g. "/users/active") wins over a
+// parameterized one (e.g. "/users/{id}") when both would otherwise match.
+// Ties go to whichever route was registered first.
+func (m *patternMux) match(path string) http.HandlerFunc {
+	pathSegments := strings.Split(path, "/")
+
+	var best *patternRoute
+	bestWildcards := -1
+	for i := range m.routes {
+		route := &m.routes[i]
+		wildcards, ok := matchSegments(route.segments, pathSegments)
+		if !ok {
+			continue
+		}
+		if best == nil || wildcards < bestWildcards {
+			best = route
+			bestWildcards = wildcards
+		}
+	}
+	if best == nil {
+		return nil
+	}
+	return best.handler
+}
+
+// matchSegments reports whether pathSegments satisfies patternSegments
+// (same length, every non-"{param}" segment equal), and if so how many
+// "{param}" segments were involved.
+func matchSegments(patternSegments, pathSegments []string) (wildcards int, ok bool) {
+	if len(patternSegments) != len(pathSegments) {
+		return 0, false
+	}
+	for i, seg := range patternSegments {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			wildcards++
+			continue
+		}
+		if seg != pathSegments[i] {
+			return 0, false
+		}
+	}
+	return wildcards, true
+}
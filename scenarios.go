@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Scenario is one possible response for an endpoint, selected by matching
+// the incoming request against When (in declaration order) and, when
+// multiple scenarios match, by Weight.
+type Scenario struct {
+	When       *ScenarioMatcher  `json:"when,omitempty" yaml:"when,omitempty"`
+	Weight     float64           `json:"weight,omitempty" yaml:"weight,omitempty"`
+	Chaos      *ChaosConfig      `json:"chaos,omitempty" yaml:"chaos,omitempty"`
+	StatusCode int               `json:"statusCode,omitempty" yaml:"statusCode,omitempty"`
+	Response   any               `json:"response,omitempty" yaml:"response,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+}
+
+// ScenarioMatcher constrains which requests a Scenario applies to. A
+// Scenario with a nil When always matches, which makes a trailing
+// when-less scenario a natural catch-all/default.
+type ScenarioMatcher struct {
+	Query   map[string]MatchRule `json:"query,omitempty" yaml:"query,omitempty"`
+	Headers map[string]MatchRule `json:"headers,omitempty" yaml:"headers,omitempty"`
+	Params  map[string]MatchRule `json:"params,omitempty" yaml:"params,omitempty"`
+	// Body keys are JSONPath-ish expressions into the parsed request
+	// body, e.g. "$.user.id" or "items[0].status".
+	Body map[string]MatchRule `json:"body,omitempty" yaml:"body,omitempty"`
+}
+
+// MatchRule is a single-field matcher; exactly one of Eq, Contains, Regex
+// is expected to be set.
+type MatchRule struct {
+	Eq       any    `json:"eq,omitempty" yaml:"eq,omitempty"`
+	Contains string `json:"contains,omitempty" yaml:"contains,omitempty"`
+	Regex    string `json:"regex,omitempty" yaml:"regex,omitempty"`
+}
+
+func (m MatchRule) matches(value string) bool {
+	switch {
+	case m.Eq != nil:
+		return fmt.Sprintf("%v", m.Eq) == value
+	case m.Contains != "":
+		return strings.Contains(value, m.Contains)
+	case m.Regex != "":
+		re, err := regexp.Compile(m.Regex)
+		return err == nil && re.MatchString(value)
+	default:
+		return true
+	}
+}
+
+// ChaosConfig injects artificial latency and failures into a scenario's
+// response, for modeling rate limits, timeouts, and flaky upstreams.
+type ChaosConfig struct {
+	DelayMs        int     `json:"delayMs,omitempty" yaml:"delayMs,omitempty"`
+	DelayJitterMs  int     `json:"delayJitterMs,omitempty" yaml:"delayJitterMs,omitempty"`
+	ErrorRate      float64 `json:"errorRate,omitempty" yaml:"errorRate,omitempty"`
+	ErrorStatus    int     `json:"errorStatus,omitempty" yaml:"errorStatus,omitempty"`
+	DropConnection bool    `json:"dropConnection,omitempty" yaml:"dropConnection,omitempty"`
+}
+
+// handleScenarios picks the best-matching scenario for r, applies its
+// chaos config, and renders its response (templated if Response is a
+// string).
+func handleScenarios(endpoint *Endpoint, pathPattern string, w http.ResponseWriter, r *http.Request) {
+	params := extractPathValues(pathPattern, r.URL.Path)
+
+	var bodyBytes []byte
+	if r.Body != nil {
+		bodyBytes, _ = io.ReadAll(r.Body)
+	}
+
+	scenario := selectScenario(endpoint.Scenarios, r, params, bodyBytes)
+	if scenario == nil {
+		http.Error(w, "no scenario matched this request", http.StatusNotFound)
+		return
+	}
+
+	if scenario.Chaos != nil {
+		status, drop := applyChaos(scenario.Chaos)
+		if drop {
+			dropConnection(w)
+			return
+		}
+		if status != 0 {
+			writeScenarioHeaders(w, scenario.Headers)
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]string{"error": "chaos injected failure"})
+			return
+		}
+	}
+
+	writeScenarioHeaders(w, scenario.Headers)
+
+	var decodedBody any
+	if len(bodyBytes) > 0 {
+		_ = json.Unmarshal(bodyBytes, &decodedBody)
+	}
+
+	rendered, err := renderScenarioResponse(scenario.Response, params, r, decodedBody)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":   "template rendering failed",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	statusCode := scenario.StatusCode
+	if statusCode == 0 {
+		statusCode = 200
+	}
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(rendered)
+}
+
+func writeScenarioHeaders(w http.ResponseWriter, headers map[string]string) {
+	for k, v := range headers {
+		w.Header().Set(k, v)
+	}
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+}
+
+// selectScenario evaluates scenarios in order, collects every match, and
+// picks one by weight (defaulting every scenario's weight to 1).
+func selectScenario(scenarios []Scenario, r *http.Request, params map[string]string, body []byte) *Scenario {
+	var matched []*Scenario
+	for i := range scenarios {
+		if scenarioMatches(&scenarios[i], r, params, body) {
+			matched = append(matched, &scenarios[i])
+		}
+	}
+	if len(matched) == 0 {
+		return nil
+	}
+	if len(matched) == 1 {
+		return matched[0]
+	}
+	return weightedPick(matched)
+}
+
+func scenarioMatches(s *Scenario, r *http.Request, params map[string]string, body []byte) bool {
+	if s.When == nil {
+		return true
+	}
+	for key, rule := range s.When.Query {
+		if !rule.matches(r.URL.Query().Get(key)) {
+			return false
+		}
+	}
+	for key, rule := range s.When.Headers {
+		if !rule.matches(r.Header.Get(key)) {
+			return false
+		}
+	}
+	for key, rule := range s.When.Params {
+		if !rule.matches(params[key]) {
+			return false
+		}
+	}
+	for path, rule := range s.When.Body {
+		if !rule.matches(jsonPathString(body, path)) {
+			return false
+		}
+	}
+	return true
+}
+
+func weightedPick(scenarios []*Scenario) *Scenario {
+	total := 0.0
+	for _, s := range scenarios {
+		total += scenarioWeight(s)
+	}
+	roll := rand.Float64() * total
+	for _, s := range scenarios {
+		w := scenarioWeight(s)
+		if roll < w {
+			return s
+		}
+		roll -= w
+	}
+	return scenarios[len(scenarios)-1]
+}
+
+func scenarioWeight(s *Scenario) float64 {
+	if s.Weight <= 0 {
+		return 1
+	}
+	return s.Weight
+}
+
+// applyChaos sleeps for the configured delay (plus jitter), then reports
+// whether the caller should drop the connection or force an error status.
+func applyChaos(chaos *ChaosConfig) (errorStatus int, drop bool) {
+	delay := chaos.DelayMs
+	if chaos.DelayJitterMs > 0 {
+		delay += rand.Intn(chaos.DelayJitterMs)
+	}
+	if delay > 0 {
+		time.Sleep(time.Duration(delay) * time.Millisecond)
+	}
+
+	if chaos.DropConnection {
+		return 0, true
+	}
+	if chaos.ErrorRate > 0 && rand.Float64() < chaos.ErrorRate {
+		status := chaos.ErrorStatus
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		return status, false
+	}
+	return 0, false
+}
+
+func dropConnection(w http.ResponseWriter) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	conn.Close()
+}
+
+// jsonPathString resolves a small dotted/bracket-index JSONPath subset
+// (e.g. "$.user.id", "items[0].status") against body and stringifies the
+// result for matching.
+func jsonPathString(body []byte, path string) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var data any
+	if json.Unmarshal(body, &data) != nil {
+		return ""
+	}
+
+	path = strings.TrimPrefix(path, "$")
+	path = strings.TrimPrefix(path, ".")
+
+	current := data
+	for _, segment := range strings.Split(path, ".") {
+		if segment == "" {
+			continue
+		}
+		name, idx, hasIdx := parsePathSegment(segment)
+		m, ok := current.(map[string]any)
+		if !ok {
+			return ""
+		}
+		current = m[name]
+		if hasIdx {
+			arr, ok := current.([]any)
+			if !ok || idx < 0 || idx >= len(arr) {
+				return ""
+			}
+			current = arr[idx]
+		}
+	}
+
+	switch v := current.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		b, _ := json.Marshal(v)
+		return string(b)
+	}
+}
+
+func parsePathSegment(segment string) (name string, idx int, hasIdx bool) {
+	open := strings.Index(segment, "[")
+	if open < 0 || !strings.HasSuffix(segment, "]") {
+		return segment, 0, false
+	}
+	n, err := strconv.Atoi(segment[open+1 : len(segment)-1])
+	if err != nil {
+		return segment, 0, false
+	}
+	return segment[:open], n, true
+}
+
+// scenarioTemplateData is the context exposed to a scenario's response
+// template.
+type scenarioTemplateData struct {
+	Request any
+	Params  map[string]string
+	Query   map[string]string
+}
+
+// renderScenarioResponse executes resp as a Go text/template if it's a
+// string, re-parsing the output as JSON so scenarios can template
+// structured bodies; non-string responses pass through unchanged.
+func renderScenarioResponse(resp any, params map[string]string, r *http.Request, body any) (any, error) {
+	str, ok := resp.(string)
+	if !ok {
+		return resp, nil
+	}
+
+	tmpl, err := template.New("response").Funcs(template.FuncMap{
+		"uuid":    generateUUID,
+		"now":     func() string { return time.Now().UTC().Format(time.RFC3339) },
+		"randInt": jsRandomInt,
+	}).Parse(str)
+	if err != nil {
+		return nil, err
+	}
+
+	query := make(map[string]string)
+	for k, v := range r.URL.Query() {
+		if len(v) > 0 {
+			query[k] = v[0]
+		}
+	}
+
+	var buf bytes.Buffer
+	data := scenarioTemplateData{Request: body, Params: params, Query: query}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+
+	var parsed any
+	if json.Unmarshal(buf.Bytes(), &parsed) == nil {
+		return parsed, nil
+	}
+	return buf.String(), nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatchRuleMatches(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  MatchRule
+		value string
+		want  bool
+	}{
+		{"empty rule always matches", MatchRule{}, "anything", true},
+		{"eq matches equal value", MatchRule{Eq: "active"}, "active", true},
+		{"eq rejects different value", MatchRule{Eq: "active"}, "inactive", false},
+		{"contains matches substring", MatchRule{Contains: "err"}, "an error occurred", true},
+		{"contains rejects missing substring", MatchRule{Contains: "err"}, "all good", false},
+		{"regex matches pattern", MatchRule{Regex: `^\d+$`}, "12345", true},
+		{"regex rejects non-matching value", MatchRule{Regex: `^\d+$`}, "abc", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.matches(tt.value); got != tt.want {
+				t.Fatalf("MatchRule.matches(%q) = %v; want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScenarioMatchesQueryHeaderParams(t *testing.T) {
+	s := &Scenario{
+		When: &ScenarioMatcher{
+			Query:   map[string]MatchRule{"plan": {Eq: "pro"}},
+			Headers: map[string]MatchRule{"X-Tenant": {Eq: "acme"}},
+			Params:  map[string]MatchRule{"id": {Regex: `^\d+$`}},
+		},
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/users/42?plan=pro", nil)
+	r.Header.Set("X-Tenant", "acme")
+
+	if !scenarioMatches(s, r, map[string]string{"id": "42"}, nil) {
+		t.Fatal("expected scenario to match request satisfying all constraints")
+	}
+
+	r.Header.Set("X-Tenant", "other")
+	if scenarioMatches(s, r, map[string]string{"id": "42"}, nil) {
+		t.Fatal("expected scenario not to match once a header constraint fails")
+	}
+}
+
+func TestScenarioMatchesBodyJSONPath(t *testing.T) {
+	s := &Scenario{
+		When: &ScenarioMatcher{
+			Body: map[string]MatchRule{"$.user.role": {Eq: "admin"}},
+		},
+	}
+	r := httptest.NewRequest(http.MethodPost, "/users", nil)
+
+	body := []byte(`{"user":{"role":"admin"}}`)
+	if !scenarioMatches(s, r, nil, body) {
+		t.Fatal("expected scenario to match body field via jsonPathString")
+	}
+
+	body = []byte(`{"user":{"role":"member"}}`)
+	if scenarioMatches(s, r, nil, body) {
+		t.Fatal("expected scenario not to match once body field differs")
+	}
+}
+
+func TestSelectScenarioPicksFirstSingleMatch(t *testing.T) {
+	scenarios := []Scenario{
+		{When: &ScenarioMatcher{Query: map[string]MatchRule{"plan": {Eq: "pro"}}}, StatusCode: 201},
+		{When: &ScenarioMatcher{Query: map[string]MatchRule{"plan": {Eq: "free"}}}, StatusCode: 200},
+	}
+	r := httptest.NewRequest(http.MethodGet, "/x?plan=pro", nil)
+
+	got := selectScenario(scenarios, r, nil, nil)
+	if got == nil || got.StatusCode != 201 {
+		t.Fatalf("selectScenario() = %+v; want the pro-plan scenario", got)
+	}
+}
+
+func TestSelectScenarioReturnsNilWhenNothingMatches(t *testing.T) {
+	scenarios := []Scenario{
+		{When: &ScenarioMatcher{Query: map[string]MatchRule{"plan": {Eq: "pro"}}}, StatusCode: 201},
+	}
+	r := httptest.NewRequest(http.MethodGet, "/x", nil)
+
+	if got := selectScenario(scenarios, r, nil, nil); got != nil {
+		t.Fatalf("selectScenario() = %+v; want nil", got)
+	}
+}
+
+func TestWeightedPickRespectsZeroAndNegativeWeightsAsDefaultOne(t *testing.T) {
+	rand.Seed(1)
+	a := &Scenario{StatusCode: 1, Weight: 0}
+	b := &Scenario{StatusCode: 2, Weight: -5}
+
+	seen := map[int]bool{}
+	for i := 0; i < 50; i++ {
+		picked := weightedPick([]*Scenario{a, b})
+		seen[picked.StatusCode] = true
+	}
+	if !seen[1] || !seen[2] {
+		t.Fatalf("expected both equally-weighted (defaulted to 1) scenarios to be picked over many draws, got %v", seen)
+	}
+}
+
+func TestWeightedPickPicksHeavilyWeightedScenario(t *testing.T) {
+	a := &Scenario{StatusCode: 1, Weight: 100}
+	b := &Scenario{StatusCode: 2, Weight: 0.0001}
+
+	counts := map[int]int{}
+	for i := 0; i < 200; i++ {
+		picked := weightedPick([]*Scenario{a, b})
+		counts[picked.StatusCode]++
+	}
+	if counts[1] == 0 {
+		t.Fatalf("expected the heavily-weighted scenario to be picked at least once, got counts %v", counts)
+	}
+}
+
+func TestRenderScenarioResponseNonStringPassesThrough(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/x", nil)
+	resp := map[string]any{"status": "ok"}
+
+	got, err := renderScenarioResponse(resp, nil, r, nil)
+	if err != nil {
+		t.Fatalf("renderScenarioResponse returned error: %v", err)
+	}
+	m, ok := got.(map[string]any)
+	if !ok || m["status"] != "ok" {
+		t.Fatalf("renderScenarioResponse() = %+v; want passthrough of the original map", got)
+	}
+}
+
+func TestRenderScenarioResponseTemplatesParamsAndBody(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/x?tier=gold", nil)
+	params := map[string]string{"id": "42"}
+	body := map[string]any{"name": "ada"}
+
+	tmpl := `{"id":"{{.Params.id}}","tier":"{{.Query.tier}}","name":"{{.Request.name}}"}`
+	got, err := renderScenarioResponse(tmpl, params, r, body)
+	if err != nil {
+		t.Fatalf("renderScenarioResponse returned error: %v", err)
+	}
+
+	m, ok := got.(map[string]any)
+	if !ok {
+		t.Fatalf("renderScenarioResponse() = %+v (%T); want a parsed JSON object", got, got)
+	}
+	if m["id"] != "42" || m["tier"] != "gold" || m["name"] != "ada" {
+		t.Fatalf("renderScenarioResponse() = %+v; want id=42, tier=gold, name=ada", m)
+	}
+}
+
+func TestJSONPathStringIndexedSegment(t *testing.T) {
+	body := []byte(`{"items":[{"status":"shipped"},{"status":"pending"}]}`)
+	if got := jsonPathString(body, "items[1].status"); got != "pending" {
+		t.Fatalf("jsonPathString() = %q; want %q", got, "pending")
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// serverDeps are the long-lived dependencies shared across reloads; only
+// the config-derived serverState is rebuilt and swapped on reload.
+type serverDeps struct {
+	jsRuntime     *JSRuntime
+	metrics       *MetricsRegistry
+	requestLogger *RequestLogger
+	proxyHandler  *ProxyHandler
+}
+
+// serverState is the immutable, config-derived half of the server: the
+// endpoint mux and the OpenAPI spec it was built from. A reload builds a
+// new serverState and swaps it in atomically.
+type serverState struct {
+	config      *Config
+	openAPISpec map[string]any
+	mux         *patternMux
+}
+
+func buildServerState(cfg *Config, deps *serverDeps) *serverState {
+	mux := &patternMux{}
+
+	pathEndpoints := make(map[string][]Endpoint)
+	for _, ep := range cfg.Endpoints {
+		if ep.Protocol == "grpc" {
+			// Served from the separate gRPC server (see grpc.go), not the
+			// HTTP mux; it typically has no meaningful Path at all.
+			continue
+		}
+		pathEndpoints[ep.Path] = append(pathEndpoints[ep.Path], ep)
+	}
+
+	for path, endpoints := range pathEndpoints {
+		eps := endpoints // capture for closure
+		pathPattern := path
+
+		if len(eps) == 1 && eps[0].Protocol == "ws" {
+			// WebSocket connections hold the handler open for the
+			// connection's lifetime, which doesn't fit the instrumented
+			// (scriptFailed, scriptMs) request/response contract, so they're
+			// registered directly rather than through instrumentHandler.
+			wsEndpoint := &eps[0]
+			mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+				handleWS(wsEndpoint, w, r)
+			})
+			log.Printf("Registered: WS %s", path)
+			continue
+		}
+
+		mux.HandleFunc(path, instrumentHandler(deps.metrics, deps.requestLogger, pathPattern, func(w http.ResponseWriter, r *http.Request) (scriptFailed bool, scriptMs float64) {
+			var endpoint *Endpoint
+			for i := range eps {
+				if eps[i].Method == "" || eps[i].Method == r.Method {
+					endpoint = &eps[i]
+					break
+				}
+			}
+
+			if endpoint == nil {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return false, 0
+			}
+
+			if endpoint.Proxy != nil {
+				deps.proxyHandler.Handle(endpoint.Proxy, pathPattern, w, r)
+				return false, 0
+			}
+
+			if len(endpoint.Scenarios) > 0 {
+				handleScenarios(endpoint, pathPattern, w, r)
+				return false, 0
+			}
+
+			if endpoint.specOp != nil {
+				if issues := validateRequest(endpoint.specOp, r, extractPathValues(pathPattern, r.URL.Path)); len(issues) > 0 {
+					writeValidationError(w, http.StatusBadRequest, "request validation failed", issues)
+					return false, 0
+				}
+			}
+
+			for k, v := range endpoint.Headers {
+				w.Header().Set(k, v)
+			}
+			if w.Header().Get("Content-Type") == "" {
+				w.Header().Set("Content-Type", "application/json")
+			}
+
+			if endpoint.Script != "" {
+				reqData := RequestData{
+					Method:  r.Method,
+					Path:    r.URL.Path,
+					Query:   make(map[string]string),
+					Headers: make(map[string]string),
+					Params:  extractPathValues(pathPattern, r.URL.Path),
+				}
+				for key, values := range r.URL.Query() {
+					if len(values) > 0 {
+						reqData.Query[key] = values[0]
+					}
+				}
+				for key, values := range r.Header {
+					if len(values) > 0 {
+						reqData.Headers[key] = values[0]
+					}
+				}
+				if r.Body != nil {
+					bodyBytes, err := io.ReadAll(r.Body)
+					if err == nil && len(bodyBytes) > 0 {
+						var bodyData any
+						if json.Unmarshal(bodyBytes, &bodyData) == nil {
+							reqData.Body = bodyData
+						} else {
+							reqData.Body = string(bodyBytes)
+						}
+					}
+				}
+
+				scriptStart := time.Now()
+				result, err := deps.jsRuntime.Execute(endpoint.Script, reqData, endpoint.Context, endpoint.ScriptTimeoutMs)
+				scriptMs = float64(time.Since(scriptStart).Microseconds()) / 1000.0
+				if err != nil {
+					log.Printf("Script error for %s %s: %v", r.Method, r.URL.Path, err)
+					w.WriteHeader(http.StatusInternalServerError)
+					json.NewEncoder(w).Encode(map[string]string{
+						"error":   "Script execution failed",
+						"details": err.Error(),
+					})
+					return true, scriptMs
+				}
+
+				for k, v := range result.Headers {
+					w.Header().Set(k, v)
+				}
+				statusCode := result.StatusCode
+				if statusCode == 0 {
+					statusCode = endpoint.StatusCode
+				}
+				if statusCode == 0 {
+					statusCode = 200
+				}
+				if endpoint.specOp != nil {
+					if issues := validateResponse(endpoint.specOp, statusCode, result.Body); len(issues) > 0 {
+						writeValidationError(w, http.StatusInternalServerError, "response validation failed", issues)
+						return false, scriptMs
+					}
+				}
+				w.WriteHeader(statusCode)
+				json.NewEncoder(w).Encode(result.Body)
+				return false, scriptMs
+			}
+
+			statusCode := endpoint.StatusCode
+			if statusCode == 0 {
+				statusCode = 200
+			}
+			if endpoint.specOp != nil {
+				if issues := validateResponse(endpoint.specOp, statusCode, endpoint.Response); len(issues) > 0 {
+					writeValidationError(w, http.StatusInternalServerError, "response validation failed", issues)
+					return false, 0
+				}
+			}
+			w.WriteHeader(statusCode)
+			json.NewEncoder(w).Encode(endpoint.Response)
+			return false, 0
+		}))
+
+		for _, ep := range eps {
+			scriptIndicator := ""
+			if ep.Script != "" {
+				scriptIndicator = " [scripted]"
+			}
+			log.Printf("Registered: %s %s%s", ep.Method, ep.Path, scriptIndicator)
+		}
+	}
+
+	return &serverState{
+		config:      cfg,
+		openAPISpec: generateOpenAPISpec(cfg),
+		mux:         mux,
+	}
+}
+
+// diffEndpoints describes what changed between two endpoint sets, keyed by
+// "METHOD path", for logging on reload.
+func diffEndpoints(old, new *Config) (added, removed, changed []string) {
+	oldByKey := endpointsByKey(old)
+	newByKey := endpointsByKey(new)
+
+	keys := make(map[string]bool)
+	for k := range oldByKey {
+		keys[k] = true
+	}
+	for k := range newByKey {
+		keys[k] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		oldEp, hadOld := oldByKey[k]
+		newEp, hasNew := newByKey[k]
+		switch {
+		case !hadOld && hasNew:
+			added = append(added, k)
+		case hadOld && !hasNew:
+			removed = append(removed, k)
+		case hadOld && hasNew && !endpointsEqual(oldEp, newEp):
+			changed = append(changed, k)
+		}
+	}
+	return added, removed, changed
+}
+
+// endpointsEqual compares the serializable parts of two endpoints,
+// ignoring specOp (a *openapi3.Operation pointer that differs across
+// reloads even when the underlying spec is unchanged).
+func endpointsEqual(a, b Endpoint) bool {
+	a.specOp, b.specOp = nil, nil
+	return reflect.DeepEqual(a, b)
+}
+
+func endpointsByKey(cfg *Config) map[string]Endpoint {
+	if cfg == nil {
+		return nil
+	}
+	out := make(map[string]Endpoint, len(cfg.Endpoints))
+	for _, ep := range cfg.Endpoints {
+		method := ep.Method
+		if method == "" {
+			method = "GET"
+		}
+		out[method+" "+ep.Path] = ep
+	}
+	return out
+}
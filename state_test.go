@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestDeps() *serverDeps {
+	return &serverDeps{
+		jsRuntime:     NewJSRuntime(NewKVStore(""), nil),
+		metrics:       NewMetricsRegistry(),
+		requestLogger: NewRequestLogger("text"),
+		proxyHandler:  NewProxyHandler(),
+	}
+}
+
+// TestServerStateMuxRoutesParameterizedPath drives a real *http.Request
+// through serverState.mux (not a hand-built params map) to prove a
+// "{param}" pattern actually routes and extracts the path value.
+func TestServerStateMuxRoutesParameterizedPath(t *testing.T) {
+	cfg := &Config{
+		Endpoints: []Endpoint{
+			{
+				Path:       "/users/{id}",
+				Method:     "GET",
+				StatusCode: 200,
+				Script:     `res.json({id: req.params.id})`,
+			},
+		},
+	}
+
+	state := buildServerState(cfg, newTestDeps())
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42", nil)
+	rec := httptest.NewRecorder()
+	state.mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /users/42 = %d; want 200, body: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Body.String() != `{"id":"42"}`+"\n" {
+		t.Fatalf("GET /users/42 body = %q; want the request's id path param echoed back", rec.Body.String())
+	}
+}
+
+// TestServerStateMuxPrefersLiteralOverPattern proves a literal
+// registration wins over an overlapping "{param}" pattern for the same
+// request path.
+func TestServerStateMuxPrefersLiteralOverPattern(t *testing.T) {
+	cfg := &Config{
+		Endpoints: []Endpoint{
+			{Path: "/users/{id}", Method: "GET", StatusCode: 200, Response: "by-id"},
+			{Path: "/users/active", Method: "GET", StatusCode: 200, Response: "active"},
+		},
+	}
+
+	state := buildServerState(cfg, newTestDeps())
+
+	req := httptest.NewRequest(http.MethodGet, "/users/active", nil)
+	rec := httptest.NewRecorder()
+	state.mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /users/active = %d; want 200, body: %s", rec.Code, rec.Body.String())
+	}
+	if rec.Body.String() != `"active"`+"\n" {
+		t.Fatalf("GET /users/active body = %q; want the literal route's response", rec.Body.String())
+	}
+}
+
+// TestServerStateMuxUnmatchedPath404s proves a request that matches no
+// registered pattern 404s rather than panicking or falling through.
+func TestServerStateMuxUnmatchedPath404s(t *testing.T) {
+	cfg := &Config{
+		Endpoints: []Endpoint{
+			{Path: "/users/{id}", Method: "GET", StatusCode: 200, Response: "ok"},
+		},
+	}
+
+	state := buildServerState(cfg, newTestDeps())
+
+	req := httptest.NewRequest(http.MethodGet, "/users/42/posts", nil)
+	rec := httptest.NewRecorder()
+	state.mux.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("GET /users/42/posts = %d; want 404", rec.Code)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+)
+
+// validationIssue is one field/path-level failure, shaped so operators can
+// locate the offending part of the request or response.
+type validationIssue struct {
+	Message string `json:"message"`
+	Path    string `json:"path,omitempty"`
+}
+
+// validateRequest checks r against op's declared parameters and request
+// body. The request body is restored onto r.Body afterwards so the normal
+// handler can still read it.
+func validateRequest(op *openapi3.Operation, r *http.Request, pathParams map[string]string) []validationIssue {
+	if op == nil {
+		return nil
+	}
+
+	var bodyBytes []byte
+	if r.Body != nil {
+		bodyBytes, _ = io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+	}
+
+	input := &openapi3filter.RequestValidationInput{
+		Request:    r,
+		PathParams: pathParams,
+	}
+
+	var issues []validationIssue
+	ctx := context.Background()
+
+	for _, paramRef := range op.Parameters {
+		if paramRef.Value == nil {
+			continue
+		}
+		if err := openapi3filter.ValidateParameter(ctx, input, paramRef.Value); err != nil {
+			issues = append(issues, validationIssue{Message: err.Error(), Path: paramRef.Value.In + "." + paramRef.Value.Name})
+		}
+	}
+
+	if op.RequestBody != nil && op.RequestBody.Value != nil {
+		input.Request.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+		if err := openapi3filter.ValidateRequestBody(ctx, input, op.RequestBody.Value); err != nil {
+			issues = append(issues, validationIssue{Message: err.Error(), Path: "body"})
+		}
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+	return issues
+}
+
+// validateResponse checks a response body against the schema declared for
+// statusCode (falling back to "default") on op.
+func validateResponse(op *openapi3.Operation, statusCode int, body any) []validationIssue {
+	if op == nil || op.Responses == nil {
+		return nil
+	}
+
+	ref, ok := op.Responses[strconv.Itoa(statusCode)]
+	if !ok {
+		ref, ok = op.Responses["default"]
+	}
+	if !ok || ref.Value == nil {
+		return nil
+	}
+
+	media, ok := ref.Value.Content["application/json"]
+	if !ok || media.Schema == nil || media.Schema.Value == nil {
+		return nil
+	}
+
+	if err := media.Schema.Value.VisitJSON(body); err != nil {
+		return []validationIssue{{Message: err.Error(), Path: "response body"}}
+	}
+	return nil
+}
+
+// writeValidationError responds with a structured 400/500 describing why
+// validation against the OpenAPI spec failed.
+func writeValidationError(w http.ResponseWriter, status int, message string, issues []validationIssue) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error":  message,
+		"issues": issues,
+	})
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/gorilla/websocket"
+)
+
+// WSConfig drives a protocol: ws endpoint: after the HTTP connection is
+// upgraded, the conversation is either a declarative list of Steps or a
+// Script exposing the ws.send/onMessage/close globals. Exactly one of the
+// two is expected to be set.
+type WSConfig struct {
+	Script string   `json:"script,omitempty" yaml:"script,omitempty"`
+	Steps  []WSStep `json:"steps,omitempty" yaml:"steps,omitempty"`
+}
+
+// WSStep is one turn of a declarative conversation: wait for a message
+// containing OnReceive (skip waiting if empty), then after DelayMs send
+// Send back to the client.
+type WSStep struct {
+	OnReceive string `json:"onReceive,omitempty" yaml:"onReceive,omitempty"`
+	Send      any    `json:"send,omitempty" yaml:"send,omitempty"`
+	DelayMs   int    `json:"delayMs,omitempty" yaml:"delayMs,omitempty"`
+}
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// handleWS upgrades the connection and drives endpoint's configured
+// conversation to completion.
+func handleWS(endpoint *Endpoint, w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("ws upgrade failed for %s: %v", r.URL.Path, err)
+		return
+	}
+	defer conn.Close()
+
+	if endpoint.WS == nil {
+		return
+	}
+
+	if endpoint.WS.Script != "" {
+		driveScriptedWS(conn, endpoint.WS.Script)
+		return
+	}
+	driveDeclarativeWS(conn, endpoint.WS.Steps)
+}
+
+func driveDeclarativeWS(conn *websocket.Conn, steps []WSStep) {
+	for _, step := range steps {
+		if step.OnReceive != "" {
+			for {
+				_, msg, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				if strings.Contains(string(msg), step.OnReceive) {
+					break
+				}
+			}
+		}
+		if step.DelayMs > 0 {
+			time.Sleep(time.Duration(step.DelayMs) * time.Millisecond)
+		}
+		if step.Send != nil {
+			if err := conn.WriteJSON(step.Send); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// wsBridge is the `ws` global exposed to scripted WS endpoints. Method
+// names are lowercased by goja's field mapper, so scripts call
+// ws.send(msg), ws.onMessage(fn), ws.close().
+type wsBridge struct {
+	conn      *websocket.Conn
+	onMessage goja.Callable
+}
+
+func (b *wsBridge) Send(msg any) {
+	_ = b.conn.WriteJSON(msg)
+}
+
+func (b *wsBridge) OnMessage(fn goja.Callable) {
+	b.onMessage = fn
+}
+
+func (b *wsBridge) Close() {
+	_ = b.conn.Close()
+}
+
+func driveScriptedWS(conn *websocket.Conn, script string) {
+	vm := goja.New()
+	vm.SetFieldNameMapper(goja.UncapFieldNameMapper())
+
+	bridge := &wsBridge{conn: conn}
+	vm.Set("ws", bridge)
+
+	if _, err := vm.RunString(script); err != nil {
+		log.Printf("ws script error: %v", err)
+		return
+	}
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		if bridge.onMessage == nil {
+			continue
+		}
+
+		var payload any
+		if json.Unmarshal(msg, &payload) != nil {
+			payload = string(msg)
+		}
+		if _, err := bridge.onMessage(goja.Undefined(), vm.ToValue(payload)); err != nil {
+			log.Printf("ws onMessage handler error: %v", err)
+		}
+	}
+}